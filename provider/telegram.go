@@ -5,9 +5,8 @@ import (
 	"crypto/sha1"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
-	neturl "net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -23,26 +22,100 @@ import (
 type TelegramHandler struct {
 	logger.L
 
-	ProviderName         string
-	ErrorMsg, SuccessMsg string
+	ProviderName                             string
+	ErrorMsg, SuccessMsg, HelpMsg, CancelMsg string
 
 	TokenService TokenService
 	AvatarSaver  AvatarSaver
 	Telegram     TelegramAPI
+	Store        AuthRequestStore // Persists pending/confirmed auth requests. Defaults to an in-memory map
+	AvatarCache  AvatarCache      // Memoizes Telegram.Avatar results. Defaults to an in-process TTL+LRU cache
 
-	mu           sync.Mutex                 // Guard for the map below
-	authRequests map[string]authRequestInfo // Tokens waiting for confirmation
+	RateLimiter       RateLimiterStore // Backs LoginHandler's rate limiting. Defaults to an in-memory store
+	RateLimit         Rate             // Per client IP. Defaults to defaultPerIPRate
+	GlobalRateLimit   Rate             // Across all clients. Defaults to defaultGlobalRate
+	TrustForwardedFor bool             // Trust X-Forwarded-For when computing the per-IP rate limit key
+	MaxAuthRequests   int              // Hard ceiling on outstanding auth requests. Defaults to defaultMaxAuthRequests
+
+	mu          sync.Mutex                // Guard for the fields below
+	webhookMode bool                      // true once UseWebhook has been called
+	secretToken string                    // compared against X-Telegram-Bot-Api-Secret-Token
+	commands    map[string]CommandHandler // extra /cmd handlers registered via RegisterCommand
+
+	initOnce sync.Once // guards the lazy defaulting of pluggable fields, see initDefaults
 }
 
+// initDefaults fills in default implementations for any pluggable field left unset,
+// so the handler is safe to use the moment it's constructed regardless of whether (or
+// when) Run has been started in its own goroutine. Safe to call concurrently from both
+// Run and every HTTP handler method; runs at most once.
+func (t *TelegramHandler) initDefaults() {
+	t.initOnce.Do(func() {
+		if t.Store == nil {
+			t.Store = NewMemoryAuthRequestStore()
+		}
+		if t.AvatarCache == nil {
+			t.AvatarCache = NewAvatarCache(defaultAvatarCacheSize, defaultAvatarCacheTTL)
+		}
+		if t.RateLimiter == nil {
+			t.RateLimiter = NewMemoryRateLimiterStore()
+		}
+		if t.RateLimit.Limit == 0 {
+			t.RateLimit = defaultPerIPRate
+		}
+		if t.GlobalRateLimit.Limit == 0 {
+			t.GlobalRateLimit = defaultGlobalRate
+		}
+		if t.MaxAuthRequests == 0 {
+			t.MaxAuthRequests = defaultMaxAuthRequests
+		}
+	})
+}
+
+// AuthRequestStore persists pending and confirmed login requests. It exists so the
+// handler isn't tied to an in-process map: requests can survive a restart or be shared
+// between replicas running behind a load balancer, by plugging in a Redis or SQL backed
+// implementation (see SQLAuthRequestStore) instead of the default NewMemoryAuthRequestStore.
+type AuthRequestStore interface {
+	// Create registers a freshly issued token, pending confirmation, expiring at expires.
+	Create(ctx context.Context, token string, expires time.Time) error
+	// CreateIfUnderLimit atomically registers token like Create, but only if fewer than
+	// maxOutstanding requests currently exist. created is false (with a nil error) if the
+	// limit was reached and no request was stored. Implementations must guard the count
+	// check and the insert with the same lock/transaction, so concurrent callers can't
+	// all observe a count under the limit and together overshoot it.
+	CreateIfUnderLimit(ctx context.Context, token string, expires time.Time, maxOutstanding int) (created bool, err error)
+	// Get returns the request for token, or ErrNotFound if it doesn't exist (or expired and was purged).
+	Get(ctx context.Context, token string) (authRequestInfo, error)
+	// Confirm marks token as confirmed by user. Returns ErrNotFound if the token doesn't exist.
+	Confirm(ctx context.Context, token string, user userInfo) error
+	// Cancel marks token as cancelled by the peer, so LoginHandler reports it as gone.
+	// Returns ErrNotFound if the token doesn't exist.
+	Cancel(ctx context.Context, token string) error
+	// Delete removes token, e.g. once it has been exchanged for a session.
+	Delete(ctx context.Context, token string) error
+	// PurgeExpired removes all requests that expired before now.
+	PurgeExpired(ctx context.Context, now time.Time) error
+	// Count returns the number of outstanding requests. LoginHandler enforces
+	// MaxAuthRequests through CreateIfUnderLimit, not through Count.
+	Count(ctx context.Context) (int, error)
+}
+
+// ErrNotFound is returned by AuthRequestStore.Get and Confirm when token is unknown.
+var ErrNotFound = errors.New("auth request not found")
+
 // TelegramAPI is used for interacting with telegram API
 type TelegramAPI interface {
 	GetUpdates(ctx context.Context) (*telegramUpdate, error)
 	Avatar(ctx context.Context, userID int) (string, error)
 	Send(ctx context.Context, id int, text string) error
+	SetWebhook(ctx context.Context, publicURL, secretToken string) error
+	DeleteWebhook(ctx context.Context) error
 }
 
 type authRequestInfo struct {
 	confirmed bool // whether login request has been confirmed and userInfo set
+	cancelled bool // whether the peer sent /cancel for this token
 	expires   time.Time
 	user      *userInfo
 }
@@ -53,16 +126,108 @@ type userInfo struct {
 	Avatar string
 }
 
+// memoryAuthRequestStore is the default AuthRequestStore, backed by an in-memory map.
+// Pending requests don't survive restarts and aren't visible to other replicas.
+type memoryAuthRequestStore struct {
+	mu       sync.Mutex
+	requests map[string]authRequestInfo
+}
+
+// NewMemoryAuthRequestStore returns the default in-memory AuthRequestStore implementation
+func NewMemoryAuthRequestStore() AuthRequestStore {
+	return &memoryAuthRequestStore{requests: make(map[string]authRequestInfo)}
+}
+
+func (s *memoryAuthRequestStore) Create(ctx context.Context, token string, expires time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests[token] = authRequestInfo{expires: expires}
+	return nil
+}
+
+func (s *memoryAuthRequestStore) CreateIfUnderLimit(ctx context.Context, token string, expires time.Time, maxOutstanding int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.requests) >= maxOutstanding {
+		return false, nil
+	}
+	s.requests[token] = authRequestInfo{expires: expires}
+	return true, nil
+}
+
+func (s *memoryAuthRequestStore) Get(ctx context.Context, token string) (authRequestInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	req, ok := s.requests[token]
+	if !ok {
+		return authRequestInfo{}, ErrNotFound
+	}
+	return req, nil
+}
+
+func (s *memoryAuthRequestStore) Confirm(ctx context.Context, token string, user userInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	req, ok := s.requests[token]
+	if !ok {
+		return ErrNotFound
+	}
+	req.confirmed = true
+	req.user = &user
+	s.requests[token] = req
+	return nil
+}
+
+func (s *memoryAuthRequestStore) Cancel(ctx context.Context, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	req, ok := s.requests[token]
+	if !ok {
+		return ErrNotFound
+	}
+	req.cancelled = true
+	s.requests[token] = req
+	return nil
+}
+
+func (s *memoryAuthRequestStore) Delete(ctx context.Context, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.requests, token)
+	return nil
+}
+
+func (s *memoryAuthRequestStore) PurgeExpired(ctx context.Context, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for token, req := range s.requests {
+		if now.After(req.expires) {
+			delete(s.requests, token)
+		}
+	}
+	return nil
+}
+
+func (s *memoryAuthRequestStore) Count(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.requests), nil
+}
+
 // changed in tests
 var pollInterval = time.Second
 
-// Run starts processing login requests sent in Telegram
+// Run starts processing login requests sent in Telegram.
+// In webhook mode (see UseWebhook) it only purges expired auth requests,
+// leaving update delivery to WebhookHandler.
 // Blocks caller
 func (t *TelegramHandler) Run(ctx context.Context) error {
-	// Initialization
-	t.mu.Lock()
-	t.authRequests = make(map[string]authRequestInfo)
-	t.mu.Unlock()
+	t.initDefaults()
 
 	ticker := time.NewTicker(pollInterval)
 
@@ -72,41 +237,99 @@ func (t *TelegramHandler) Run(ctx context.Context) error {
 			ticker.Stop()
 			return ctx.Err()
 		case <-ticker.C:
-			err := t.processUpdates(ctx)
-			if err != nil {
-				t.Logf("Error while processing updates: %v", err)
-				continue
-			}
-
-			// Purge expired requests
-			now := time.Now()
 			t.mu.Lock()
-			for key, req := range t.authRequests {
-				if now.After(req.expires) {
-					delete(t.authRequests, key)
+			webhookMode := t.webhookMode
+			t.mu.Unlock()
+
+			if !webhookMode {
+				if err := t.processUpdates(ctx); err != nil {
+					t.Logf("Error while processing updates: %v", err)
 				}
 			}
-			t.mu.Unlock()
+
+			if err := t.Store.PurgeExpired(ctx, time.Now()); err != nil {
+				t.Logf("failed to purge expired auth requests: %v", err)
+			}
 		}
 	}
 }
 
+// UseWebhook switches the handler from long-polling to webhook mode: it registers
+// publicURL with Telegram as the update destination and makes WebhookHandler reject
+// any request whose X-Telegram-Bot-Api-Secret-Token header doesn't match secretToken.
+// Run keeps running to purge expired auth requests, but stops polling getUpdates.
+func (t *TelegramHandler) UseWebhook(publicURL, secretToken string) error {
+	if err := t.Telegram.SetWebhook(context.Background(), publicURL, secretToken); err != nil {
+		return errors.Wrap(err, "failed to set webhook")
+	}
+
+	t.mu.Lock()
+	t.webhookMode = true
+	t.secretToken = secretToken
+	t.mu.Unlock()
+
+	return nil
+}
+
+// DeleteWebhook switches the handler back to long-polling mode, removing the
+// webhook registered with Telegram by UseWebhook.
+func (t *TelegramHandler) DeleteWebhook() error {
+	if err := t.Telegram.DeleteWebhook(context.Background()); err != nil {
+		return errors.Wrap(err, "failed to delete webhook")
+	}
+
+	t.mu.Lock()
+	t.webhookMode = false
+	t.secretToken = ""
+	t.mu.Unlock()
+
+	return nil
+}
+
 type telegramUpdate struct {
-	Result []struct {
-		UpdateID int `json:"update_id"`
-		Message  struct {
-			Chat struct {
-				ID   int    `json:"id"`
-				Name string `json:"first_name"`
-				Type string `json:"type"`
-			} `json:"chat"`
-			Text string `json:"text"`
-		} `json:"message"`
-	} `json:"result"`
-}
-
-// processUpdates processes a batch of updates from telegram servers
-// Returns offset for subsequest calls
+	Result []telegramUpdateItem `json:"result"`
+}
+
+type telegramUpdateItem struct {
+	UpdateID int `json:"update_id"`
+	Message  struct {
+		Chat struct {
+			ID   int    `json:"id"`
+			Name string `json:"first_name"`
+			Type string `json:"type"`
+		} `json:"chat"`
+		Text string `json:"text"`
+	} `json:"message"`
+}
+
+// WebhookHandler receives updates pushed by Telegram when UseWebhook is active.
+// POST /webhook
+func (t *TelegramHandler) WebhookHandler(w http.ResponseWriter, r *http.Request) {
+	t.initDefaults()
+
+	t.mu.Lock()
+	secretToken := t.secretToken
+	t.mu.Unlock()
+
+	if secretToken != "" && r.Header.Get("X-Telegram-Bot-Api-Secret-Token") != secretToken {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	var update telegramUpdateItem
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		rest.SendErrorJSON(w, r, t.L, http.StatusBadRequest, err, "failed to decode update")
+		return
+	}
+
+	if err := t.handleUpdate(r.Context(), update); err != nil {
+		t.Logf("failed to process telegram update: %v", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// processUpdates fetches a batch of updates from telegram servers and handles each of them
 func (t *TelegramHandler) processUpdates(ctx context.Context) error {
 	updates, err := t.Telegram.GetUpdates(ctx)
 	if err != nil {
@@ -114,56 +337,141 @@ func (t *TelegramHandler) processUpdates(ctx context.Context) error {
 	}
 
 	for _, update := range updates.Result {
-		if update.Message.Chat.Type != "private" {
-			continue
+		if err := t.handleUpdate(ctx, update); err != nil {
+			t.Logf("failed to process telegram update: %v", err)
 		}
+	}
 
-		if !strings.HasPrefix(update.Message.Text, "/start ") {
-			err := t.Telegram.Send(ctx, update.Message.Chat.ID, t.ErrorMsg)
-			if err != nil {
-				t.Logf("failed to notify telegram peer: %v", err)
-			}
-			continue
-		}
+	return nil
+}
 
-		token := strings.TrimPrefix(update.Message.Text, "/start ")
+// handleUpdate dispatches a single update to the /start, /help, /cancel built-ins or, if
+// none match, a CommandHandler registered via RegisterCommand. Used by both the polling
+// (processUpdates) and webhook code paths.
+func (t *TelegramHandler) handleUpdate(ctx context.Context, update telegramUpdateItem) error {
+	if update.Message.Chat.Type != "private" {
+		return nil
+	}
 
-		t.mu.Lock()
-		authRequest, ok := t.authRequests[token]
-		if !ok { // No such token
-			t.mu.Unlock()
-			err := t.Telegram.Send(ctx, update.Message.Chat.ID, t.ErrorMsg)
-			if err != nil {
-				t.Logf("failed to notify telegram peer: %v", err)
-			}
-			continue
-		}
-		t.mu.Unlock()
+	if !strings.HasPrefix(update.Message.Text, "/") {
+		return t.Telegram.Send(ctx, update.Message.Chat.ID, t.ErrorMsg)
+	}
 
-		avatarURL, err := t.Telegram.Avatar(ctx, update.Message.Chat.ID)
-		if err != nil {
-			t.Logf("failed to get user avatar: %v", err)
-			continue
-		}
+	cmd, args := splitCommand(update.Message.Text)
 
-		authRequest.confirmed = true
-		authRequest.user = &userInfo{
-			ID:     update.Message.Chat.ID,
-			Name:   update.Message.Chat.Name,
-			Avatar: avatarURL,
-		}
+	switch cmd {
+	case "start":
+		return t.handleStart(ctx, update, args)
+	case "help":
+		return t.Telegram.Send(ctx, update.Message.Chat.ID, t.HelpMsg)
+	case "cancel":
+		return t.handleCancel(ctx, update, args)
+	}
+
+	t.mu.Lock()
+	h, ok := t.commands[cmd]
+	t.mu.Unlock()
 
-		t.mu.Lock()
-		t.authRequests[token] = authRequest
-		t.mu.Unlock()
+	if !ok {
+		return t.Telegram.Send(ctx, update.Message.Chat.ID, t.ErrorMsg)
+	}
 
-		err = t.Telegram.Send(ctx, update.Message.Chat.ID, t.SuccessMsg)
-		if err != nil {
-			t.Logf("failed to notify telegram peer: %v", err)
+	return h.Handle(ctx, t.Telegram, update.Message.Chat.ID, args)
+}
+
+// splitCommand splits a "/cmd rest of text" message into its command name and argument
+// string. args is empty if the command has no arguments.
+func splitCommand(text string) (cmd, args string) {
+	text = strings.TrimPrefix(text, "/")
+	parts := strings.SplitN(text, " ", 2)
+	cmd = parts[0]
+	if len(parts) > 1 {
+		args = parts[1]
+	}
+	return cmd, args
+}
+
+// handleStart confirms the auth request identified by token, the argument to /start.
+func (t *TelegramHandler) handleStart(ctx context.Context, update telegramUpdateItem, token string) error {
+	if token == "" {
+		return t.Telegram.Send(ctx, update.Message.Chat.ID, t.ErrorMsg)
+	}
+
+	if _, err := t.Store.Get(ctx, token); err != nil { // No such token
+		return t.Telegram.Send(ctx, update.Message.Chat.ID, t.ErrorMsg)
+	}
+
+	avatarURL, err := t.avatar(ctx, update.Message.Chat.ID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get user avatar")
+	}
+
+	user := userInfo{
+		ID:     update.Message.Chat.ID,
+		Name:   update.Message.Chat.Name,
+		Avatar: avatarURL,
+	}
+
+	if err := t.Store.Confirm(ctx, token, user); err != nil {
+		return errors.Wrap(err, "failed to confirm auth request")
+	}
+
+	return t.Telegram.Send(ctx, update.Message.Chat.ID, t.SuccessMsg)
+}
+
+// handleCancel marks the auth request identified by token, the argument to /cancel, as
+// cancelled so LoginHandler reports it as gone instead of leaving it pending until it expires.
+func (t *TelegramHandler) handleCancel(ctx context.Context, update telegramUpdateItem, token string) error {
+	if token == "" {
+		return t.Telegram.Send(ctx, update.Message.Chat.ID, t.ErrorMsg)
+	}
+
+	if err := t.Store.Cancel(ctx, token); err != nil {
+		return t.Telegram.Send(ctx, update.Message.Chat.ID, t.ErrorMsg)
+	}
+
+	return t.Telegram.Send(ctx, update.Message.Chat.ID, t.CancelMsg)
+}
+
+// CommandHandler lets downstream users register bot commands beyond the built-in
+// /start, /help and /cancel, without forking TelegramHandler.
+type CommandHandler interface {
+	// Handle responds to a /cmd message, args being the text following the command name.
+	Handle(ctx context.Context, api TelegramAPI, chatID int, args string) error
+}
+
+// RegisterCommand adds a custom /name command handler. The built-in /start, /help and
+// /cancel commands can't be overridden this way.
+func (t *TelegramHandler) RegisterCommand(name string, h CommandHandler) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.commands == nil {
+		t.commands = make(map[string]CommandHandler)
+	}
+	t.commands[name] = h
+}
+
+// avatar returns the avatar URL for userID, going through AvatarCache if configured
+// (including negative caching of users with no profile photo) before falling back
+// to the Telegram API.
+func (t *TelegramHandler) avatar(ctx context.Context, userID int) (string, error) {
+	if t.AvatarCache != nil {
+		if url, ok := t.AvatarCache.Get(userID); ok {
+			return url, nil
 		}
 	}
 
-	return nil
+	url, err := t.Telegram.Avatar(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	if t.AvatarCache != nil {
+		t.AvatarCache.Set(userID, url)
+	}
+
+	return url, nil
 }
 
 // Name of the handler
@@ -174,6 +482,14 @@ var tokenLifetime = time.Minute * 10
 
 // LoginHandler generates and verifies login requests
 func (t *TelegramHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	t.initDefaults()
+
+	if limited, retryAfter := t.rateLimited(r); limited {
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+		rest.SendErrorJSON(w, r, nil, http.StatusTooManyRequests, nil, "too many requests")
+		return
+	}
+
 	queryToken := r.URL.Query().Get("token")
 	if queryToken == "" {
 		// GET /login (No token supplied)
@@ -181,29 +497,37 @@ func (t *TelegramHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 		token, err := randToken()
 		if err != nil {
 			rest.SendErrorJSON(w, r, t.L, http.StatusInternalServerError, err, "failed to generate code")
+			return
 		}
 
-		t.mu.Lock()
-		t.authRequests[token] = authRequestInfo{
-			expires: time.Now().Add(tokenLifetime),
+		created, err := t.Store.CreateIfUnderLimit(r.Context(), token, time.Now().Add(tokenLifetime), t.MaxAuthRequests)
+		if err != nil {
+			rest.SendErrorJSON(w, r, t.L, http.StatusInternalServerError, err, "failed to store auth request")
+			return
+		}
+		if !created {
+			rest.SendErrorJSON(w, r, nil, http.StatusServiceUnavailable, nil, "too many outstanding auth requests")
+			return
 		}
-		t.mu.Unlock()
 
 		fmt.Fprint(w, token)
 		return
 	}
 
 	// GET /login?token=blah
-	t.mu.Lock()
-	authRequest, ok := t.authRequests[queryToken]
-	t.mu.Unlock()
-
-	if !ok || time.Now().After(authRequest.expires) {
-		delete(t.authRequests, queryToken)
+	authRequest, err := t.Store.Get(r.Context(), queryToken)
+	if err != nil || time.Now().After(authRequest.expires) {
+		_ = t.Store.Delete(r.Context(), queryToken)
 		rest.SendErrorJSON(w, r, nil, http.StatusNotFound, nil, "request expired")
 		return
 	}
 
+	if authRequest.cancelled {
+		_ = t.Store.Delete(r.Context(), queryToken)
+		rest.SendErrorJSON(w, r, nil, http.StatusGone, nil, "request cancelled")
+		return
+	}
+
 	if !authRequest.confirmed {
 		rest.SendErrorJSON(w, r, nil, http.StatusNotFound, nil, "request not yet confirmed")
 		return
@@ -215,7 +539,7 @@ func (t *TelegramHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 		Picture: authRequest.user.Avatar,
 	}
 
-	u, err := setAvatar(t.AvatarSaver, u, &http.Client{Timeout: 5 * time.Second})
+	u, err = setAvatar(t.AvatarSaver, u, &http.Client{Timeout: 5 * time.Second})
 	if err != nil {
 		rest.SendErrorJSON(w, r, t.L, http.StatusInternalServerError, err, "failed to save avatar to proxy")
 		return
@@ -238,9 +562,9 @@ func (t *TelegramHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	rest.RenderJSON(w, r, claims.User)
 
 	// Delete request
-	t.mu.Lock()
-	defer t.mu.Unlock()
-	delete(t.authRequests, queryToken)
+	if err := t.Store.Delete(r.Context(), queryToken); err != nil {
+		t.Logf("failed to delete auth request: %v", err)
+	}
 }
 
 // AuthHandler does nothing since we're don't have any callbacks
@@ -251,145 +575,3 @@ func (t *TelegramHandler) LogoutHandler(w http.ResponseWriter, r *http.Request)
 	t.TokenService.Reset(w)
 }
 
-// tgAPI implements TelegramAPI
-type tgAPI struct {
-	logger.L
-	endpoint     string
-	token        string
-	updateOffset int
-}
-
-// NewTelegramAPI returns initialized TelegramAPI implementation
-func NewTelegramAPI(token string, l logger.L) TelegramAPI {
-	return &tgAPI{
-		L:        l,
-		endpoint: "https://api.telegram.org",
-		token:    token,
-	}
-}
-
-// GetUpdates fetches incoming updates
-func (t *tgAPI) GetUpdates(ctx context.Context) (*telegramUpdate, error) {
-	url := `getUpdates?allowed_updates=["message"]`
-	if t.updateOffset != 0 {
-		url += fmt.Sprintf("&offset=%d", t.updateOffset) // See core.telegram.org/bots/api#getupdates
-	}
-
-	var result telegramUpdate
-
-	err := t.request(ctx, url, &result)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to fetch updates")
-	}
-
-	for _, u := range result.Result {
-		if u.UpdateID >= t.updateOffset {
-			t.updateOffset = u.UpdateID + 1
-		}
-	}
-
-	return &result, err
-}
-
-// Send sends a message to telegram peer
-func (t *tgAPI) Send(ctx context.Context, id int, msg string) error {
-	url := fmt.Sprintf("%s/bot%s/sendMessage?chat_id=%d&text=%s", t.endpoint, t.token, id, neturl.PathEscape(msg))
-
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return errors.Wrap(err, "failed to create request")
-	}
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return errors.Wrap(err, "failed to send request")
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return errors.Errorf("telegram returned %d status code", resp.StatusCode)
-	}
-
-	return nil
-}
-
-// Avatar returns URL to user avatar
-func (t *tgAPI) Avatar(ctx context.Context, id int) (string, error) {
-	// Get profile pictures
-	url := fmt.Sprintf(`getUserProfilePhotos?user_id=%d`, id)
-
-	var profilePhotos = struct {
-		Result struct {
-			Photos [][]struct {
-				ID string `json:"file_id"`
-			} `json:"photos"`
-		} `json:"result"`
-	}{}
-
-	err := t.request(ctx, url, &profilePhotos)
-	if err != nil {
-		return "", err
-	}
-
-	// User does not have profile picture set or it is hidden in privacy settings
-	if len(profilePhotos.Result.Photos) == 0 {
-		return "", nil
-	}
-
-	// Get actual avatar url
-	last := len(profilePhotos.Result.Photos[0]) - 1
-	fileID := profilePhotos.Result.Photos[0][last].ID
-	url = fmt.Sprintf(`getFile?file_id=%s`, fileID)
-
-	var fileMetadata = struct {
-		Result struct {
-			Path string `json:"file_path"`
-		} `json:"result"`
-	}{}
-
-	err = t.request(ctx, url, &fileMetadata)
-	if err != nil {
-		return "", err
-	}
-
-	avatarURL := fmt.Sprintf("%s/file/bot%s/%s", t.endpoint, t.token, fileMetadata.Result.Path)
-
-	return avatarURL, nil
-}
-
-func (t *tgAPI) request(ctx context.Context, method string, data interface{}) error {
-	url := fmt.Sprintf("%s/bot%s/%s", t.endpoint, t.token, method)
-
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return errors.Wrap(err, "failed to create request")
-	}
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return errors.Wrap(err, "failed to send request")
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return t.parseError(resp.Body)
-	}
-
-	if err = json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return errors.Wrap(err, "can't decode json response")
-	}
-
-	return nil
-}
-
-func (t *tgAPI) parseError(r io.Reader) error {
-	var tgErr = struct {
-		Description string `json:"description"`
-	}{}
-
-	if err := json.NewDecoder(r).Decode(&tgErr); err != nil {
-		return errors.Wrap(err, "can't decode error")
-	}
-
-	return errors.Errorf("telegram returned error: %v", tgErr.Description)
-}
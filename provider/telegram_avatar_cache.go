@@ -0,0 +1,93 @@
+package provider
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// AvatarCache memoizes the avatarURL resulting from TelegramAPI.Avatar so repeated
+// logins by the same telegram user don't re-fetch getUserProfilePhotos/getFile on
+// every confirmation. Set is also used for negative caching: an empty url means the
+// user has no profile photo, which is worth remembering too.
+type AvatarCache interface {
+	Get(userID int) (url string, ok bool)
+	Set(userID int, url string)
+}
+
+// defaultAvatarCacheSize and defaultAvatarCacheTTL are used by initDefaults when
+// TelegramHandler.AvatarCache isn't set explicitly.
+const (
+	defaultAvatarCacheSize = 10000
+	defaultAvatarCacheTTL  = time.Hour
+)
+
+type avatarCacheEntry struct {
+	userID  int
+	url     string
+	expires time.Time
+}
+
+// ttlLRUAvatarCache is the default AvatarCache: entries expire after ttl, and once
+// size is reached the least-recently-used entry is evicted to bound memory use.
+type ttlLRUAvatarCache struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	index map[int]*list.Element
+	order *list.List // front = most recently used
+}
+
+// NewAvatarCache returns the default AvatarCache implementation, holding at most size
+// entries for up to ttl each.
+func NewAvatarCache(size int, ttl time.Duration) AvatarCache {
+	return &ttlLRUAvatarCache{
+		size:  size,
+		ttl:   ttl,
+		index: make(map[int]*list.Element, size),
+		order: list.New(),
+	}
+}
+
+func (c *ttlLRUAvatarCache) Get(userID int) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.index[userID]
+	if !ok {
+		return "", false
+	}
+
+	entry := el.Value.(*avatarCacheEntry)
+	if time.Now().After(entry.expires) {
+		c.order.Remove(el)
+		delete(c.index, userID)
+		return "", false
+	}
+
+	c.order.MoveToFront(el)
+	return entry.url, true
+}
+
+func (c *ttlLRUAvatarCache) Set(userID int, url string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := time.Now().Add(c.ttl)
+
+	if el, ok := c.index[userID]; ok {
+		el.Value.(*avatarCacheEntry).url = url
+		el.Value.(*avatarCacheEntry).expires = expires
+		c.order.MoveToFront(el)
+		return
+	}
+
+	el := c.order.PushFront(&avatarCacheEntry{userID: userID, url: url, expires: expires})
+	c.index[userID] = el
+
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.index, oldest.Value.(*avatarCacheEntry).userID)
+	}
+}
@@ -0,0 +1,73 @@
+package provider
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTtlLRUAvatarCache_SetGet(t *testing.T) {
+	c := NewAvatarCache(10, time.Hour)
+
+	if _, ok := c.Get(1); ok {
+		t.Fatal("expected a miss for a user that was never set")
+	}
+
+	c.Set(1, "http://avatar/1")
+	url, ok := c.Get(1)
+	if !ok || url != "http://avatar/1" {
+		t.Fatalf("expected a hit with the stored url, got url=%q ok=%v", url, ok)
+	}
+}
+
+func TestTtlLRUAvatarCache_NegativeCaching(t *testing.T) {
+	c := NewAvatarCache(10, time.Hour)
+
+	c.Set(1, "") // user has no profile photo
+
+	url, ok := c.Get(1)
+	if !ok {
+		t.Fatal("an empty url should still be cached, not treated as a miss")
+	}
+	if url != "" {
+		t.Fatalf("expected the cached url to stay empty, got %q", url)
+	}
+}
+
+func TestTtlLRUAvatarCache_TTLExpiry(t *testing.T) {
+	c := NewAvatarCache(10, 20*time.Millisecond)
+
+	c.Set(1, "http://avatar/1")
+	if _, ok := c.Get(1); !ok {
+		t.Fatal("expected a hit immediately after Set")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	if _, ok := c.Get(1); ok {
+		t.Fatal("expected the entry to have expired")
+	}
+}
+
+func TestTtlLRUAvatarCache_LRUEviction(t *testing.T) {
+	c := NewAvatarCache(2, time.Hour)
+
+	c.Set(1, "http://avatar/1")
+	c.Set(2, "http://avatar/2")
+
+	// Touch 1 so 2 becomes the least-recently-used entry.
+	if _, ok := c.Get(1); !ok {
+		t.Fatal("expected a hit for user 1")
+	}
+
+	c.Set(3, "http://avatar/3") // should evict user 2, not user 1
+
+	if _, ok := c.Get(2); ok {
+		t.Fatal("expected user 2 to have been evicted as least-recently-used")
+	}
+	if _, ok := c.Get(1); !ok {
+		t.Fatal("expected user 1 to still be cached")
+	}
+	if _, ok := c.Get(3); !ok {
+		t.Fatal("expected user 3 to be cached")
+	}
+}
@@ -0,0 +1,177 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-pkgz/auth/logger"
+	"github.com/pkg/errors"
+)
+
+// botClient is a minimal vendored client for the Telegram Bot API: every call is a
+// POST with a JSON body, per core.telegram.org/bots/api#making-requests. tgAPI is a
+// thin adapter over it, so TelegramAPI isn't tied to one hand-rolled implementation -
+// an adapter over a different bot library can be swapped in by satisfying TelegramAPI.
+type botClient struct {
+	logger.L
+	endpoint string
+	token    string
+}
+
+// newBotClient returns a botClient talking to the public Telegram Bot API.
+func newBotClient(token string, l logger.L) *botClient {
+	return &botClient{L: l, endpoint: "https://api.telegram.org", token: token}
+}
+
+// telegramEnvelope is the {"ok": ..., "result": ..., "description": ...} wrapper every
+// Bot API response is shaped like.
+type telegramEnvelope struct {
+	OK          bool            `json:"ok"`
+	Result      json.RawMessage `json:"result"`
+	Description string          `json:"description"`
+}
+
+// call invokes method with params marshaled as the POST body, decoding the response's
+// "result" field into out (pass nil to ignore the result).
+func (c *botClient) call(ctx context.Context, method string, params, out interface{}) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal request")
+	}
+
+	url := fmt.Sprintf("%s/bot%s/%s", c.endpoint, c.token, method)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "failed to create request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to send request")
+	}
+	defer resp.Body.Close()
+
+	var envelope telegramEnvelope
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return errors.Wrap(err, "can't decode json response")
+	}
+
+	if !envelope.OK {
+		return errors.Errorf("telegram returned error: %v", envelope.Description)
+	}
+
+	if out == nil || len(envelope.Result) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(envelope.Result, out); err != nil {
+		return errors.Wrap(err, "can't decode result")
+	}
+
+	return nil
+}
+
+// tgAPI is the default TelegramAPI implementation, a thin adapter translating each
+// method onto a botClient call.
+type tgAPI struct {
+	client       *botClient
+	updateOffset int
+}
+
+// NewTelegramAPI returns the default TelegramAPI implementation, backed by botClient
+func NewTelegramAPI(token string, l logger.L) TelegramAPI {
+	return &tgAPI{client: newBotClient(token, l)}
+}
+
+// GetUpdates fetches incoming updates
+func (t *tgAPI) GetUpdates(ctx context.Context) (*telegramUpdate, error) {
+	params := struct {
+		AllowedUpdates []string `json:"allowed_updates"`
+		Offset         int      `json:"offset,omitempty"`
+	}{AllowedUpdates: []string{"message"}, Offset: t.updateOffset}
+
+	var result []telegramUpdateItem
+	if err := t.client.call(ctx, "getUpdates", params, &result); err != nil {
+		return nil, errors.Wrap(err, "failed to fetch updates")
+	}
+
+	for _, u := range result {
+		if u.UpdateID >= t.updateOffset {
+			t.updateOffset = u.UpdateID + 1
+		}
+	}
+
+	return &telegramUpdate{Result: result}, nil
+}
+
+// Send sends a message to telegram peer. Uses POST with a JSON body rather than a query
+// string so message text isn't URL-escaped, which broke for some Unicode and long texts.
+func (t *tgAPI) Send(ctx context.Context, id int, msg string) error {
+	params := struct {
+		ChatID int    `json:"chat_id"`
+		Text   string `json:"text"`
+	}{ChatID: id, Text: msg}
+
+	return t.client.call(ctx, "sendMessage", params, nil)
+}
+
+// Avatar returns URL to user avatar
+func (t *tgAPI) Avatar(ctx context.Context, id int) (string, error) {
+	var photos struct {
+		Photos [][]struct {
+			ID string `json:"file_id"`
+		} `json:"photos"`
+	}
+
+	getPhotosParams := struct {
+		UserID int `json:"user_id"`
+	}{UserID: id}
+
+	if err := t.client.call(ctx, "getUserProfilePhotos", getPhotosParams, &photos); err != nil {
+		return "", err
+	}
+
+	// User does not have profile picture set or it is hidden in privacy settings
+	if len(photos.Photos) == 0 {
+		return "", nil
+	}
+
+	// Get actual avatar url
+	last := len(photos.Photos[0]) - 1
+	fileID := photos.Photos[0][last].ID
+
+	var file struct {
+		Path string `json:"file_path"`
+	}
+
+	getFileParams := struct {
+		FileID string `json:"file_id"`
+	}{FileID: fileID}
+
+	if err := t.client.call(ctx, "getFile", getFileParams, &file); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s/file/bot%s/%s", t.client.endpoint, t.client.token, file.Path), nil
+}
+
+// SetWebhook registers publicURL with telegram as the destination for updates
+func (t *tgAPI) SetWebhook(ctx context.Context, publicURL, secretToken string) error {
+	params := struct {
+		URL            string   `json:"url"`
+		SecretToken    string   `json:"secret_token"`
+		AllowedUpdates []string `json:"allowed_updates"`
+	}{URL: publicURL, SecretToken: secretToken, AllowedUpdates: []string{"message"}}
+
+	return t.client.call(ctx, "setWebhook", params, nil)
+}
+
+// DeleteWebhook removes a previously registered webhook, switching back to getUpdates
+func (t *tgAPI) DeleteWebhook(ctx context.Context) error {
+	return t.client.call(ctx, "deleteWebhook", struct{}{}, nil)
+}
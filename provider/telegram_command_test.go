@@ -0,0 +1,180 @@
+package provider
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// stubTelegramAPI is a bare-bones TelegramAPI recording Send calls, for tests that only
+// care about handleUpdate's dispatch logic.
+type stubTelegramAPI struct {
+	sent []stubSentMessage
+}
+
+type stubSentMessage struct {
+	chatID int
+	text   string
+}
+
+func (s *stubTelegramAPI) GetUpdates(ctx context.Context) (*telegramUpdate, error) { return nil, nil }
+func (s *stubTelegramAPI) Avatar(ctx context.Context, userID int) (string, error)  { return "", nil }
+func (s *stubTelegramAPI) SetWebhook(ctx context.Context, publicURL, secretToken string) error {
+	return nil
+}
+func (s *stubTelegramAPI) DeleteWebhook(ctx context.Context) error { return nil }
+
+func (s *stubTelegramAPI) Send(ctx context.Context, id int, text string) error {
+	s.sent = append(s.sent, stubSentMessage{chatID: id, text: text})
+	return nil
+}
+
+func privateUpdate(text string) telegramUpdateItem {
+	var u telegramUpdateItem
+	u.Message.Chat.ID = 1
+	u.Message.Chat.Name = "Alice"
+	u.Message.Chat.Type = "private"
+	u.Message.Text = text
+	return u
+}
+
+func TestSplitCommand(t *testing.T) {
+	cases := []struct {
+		text     string
+		wantCmd  string
+		wantArgs string
+	}{
+		{"/start", "start", ""},
+		{"/start abc123", "start", "abc123"},
+		{"/cancel abc123", "cancel", "abc123"},
+	}
+
+	for _, c := range cases {
+		cmd, args := splitCommand(c.text)
+		if cmd != c.wantCmd || args != c.wantArgs {
+			t.Errorf("splitCommand(%q) = (%q, %q), want (%q, %q)", c.text, cmd, args, c.wantCmd, c.wantArgs)
+		}
+	}
+}
+
+func TestTelegramHandler_handleUpdate_Builtins(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("start confirms a pending token", func(t *testing.T) {
+		api := &stubTelegramAPI{}
+		store := NewMemoryAuthRequestStore()
+		_ = store.Create(ctx, "tok1", time.Now().Add(time.Hour))
+
+		h := &TelegramHandler{Telegram: api, Store: store, SuccessMsg: "confirmed"}
+
+		if err := h.handleUpdate(ctx, privateUpdate("/start tok1")); err != nil {
+			t.Fatalf("handleUpdate failed: %v", err)
+		}
+
+		req, err := store.Get(ctx, "tok1")
+		if err != nil || !req.confirmed {
+			t.Fatalf("expected tok1 to be confirmed, got req=%+v err=%v", req, err)
+		}
+		if len(api.sent) != 1 || api.sent[0].text != "confirmed" {
+			t.Fatalf("expected a single 'confirmed' message, got %+v", api.sent)
+		}
+	})
+
+	t.Run("start with an unknown token reports an error", func(t *testing.T) {
+		api := &stubTelegramAPI{}
+		h := &TelegramHandler{Telegram: api, Store: NewMemoryAuthRequestStore(), ErrorMsg: "bad token"}
+
+		if err := h.handleUpdate(ctx, privateUpdate("/start nope")); err != nil {
+			t.Fatalf("handleUpdate failed: %v", err)
+		}
+		if len(api.sent) != 1 || api.sent[0].text != "bad token" {
+			t.Fatalf("expected a single 'bad token' message, got %+v", api.sent)
+		}
+	})
+
+	t.Run("cancel marks the request cancelled", func(t *testing.T) {
+		api := &stubTelegramAPI{}
+		store := NewMemoryAuthRequestStore()
+		_ = store.Create(ctx, "tok1", time.Now().Add(time.Hour))
+
+		h := &TelegramHandler{Telegram: api, Store: store, CancelMsg: "cancelled"}
+
+		if err := h.handleUpdate(ctx, privateUpdate("/cancel tok1")); err != nil {
+			t.Fatalf("handleUpdate failed: %v", err)
+		}
+
+		req, err := store.Get(ctx, "tok1")
+		if err != nil || !req.cancelled {
+			t.Fatalf("expected tok1 to be cancelled, got req=%+v err=%v", req, err)
+		}
+		if len(api.sent) != 1 || api.sent[0].text != "cancelled" {
+			t.Fatalf("expected a single 'cancelled' message, got %+v", api.sent)
+		}
+	})
+
+	t.Run("help sends HelpMsg", func(t *testing.T) {
+		api := &stubTelegramAPI{}
+		h := &TelegramHandler{Telegram: api, Store: NewMemoryAuthRequestStore(), HelpMsg: "help text"}
+
+		if err := h.handleUpdate(ctx, privateUpdate("/help")); err != nil {
+			t.Fatalf("handleUpdate failed: %v", err)
+		}
+		if len(api.sent) != 1 || api.sent[0].text != "help text" {
+			t.Fatalf("expected a single 'help text' message, got %+v", api.sent)
+		}
+	})
+
+	t.Run("unknown command sends ErrorMsg", func(t *testing.T) {
+		api := &stubTelegramAPI{}
+		h := &TelegramHandler{Telegram: api, Store: NewMemoryAuthRequestStore(), ErrorMsg: "unknown"}
+
+		if err := h.handleUpdate(ctx, privateUpdate("/whatever")); err != nil {
+			t.Fatalf("handleUpdate failed: %v", err)
+		}
+		if len(api.sent) != 1 || api.sent[0].text != "unknown" {
+			t.Fatalf("expected a single 'unknown' message, got %+v", api.sent)
+		}
+	})
+
+	t.Run("non-private chats are ignored", func(t *testing.T) {
+		api := &stubTelegramAPI{}
+		h := &TelegramHandler{Telegram: api, Store: NewMemoryAuthRequestStore(), ErrorMsg: "unknown"}
+
+		u := privateUpdate("/help")
+		u.Message.Chat.Type = "group"
+
+		if err := h.handleUpdate(ctx, u); err != nil {
+			t.Fatalf("handleUpdate failed: %v", err)
+		}
+		if len(api.sent) != 0 {
+			t.Fatalf("expected no messages for a non-private chat, got %+v", api.sent)
+		}
+	})
+}
+
+type stubCommandHandler struct {
+	called bool
+	args   string
+}
+
+func (c *stubCommandHandler) Handle(ctx context.Context, api TelegramAPI, chatID int, args string) error {
+	c.called = true
+	c.args = args
+	return nil
+}
+
+func TestTelegramHandler_RegisterCommand(t *testing.T) {
+	api := &stubTelegramAPI{}
+	h := &TelegramHandler{Telegram: api, Store: NewMemoryAuthRequestStore(), ErrorMsg: "unknown"}
+
+	cmd := &stubCommandHandler{}
+	h.RegisterCommand("ping", cmd)
+
+	if err := h.handleUpdate(context.Background(), privateUpdate("/ping hello")); err != nil {
+		t.Fatalf("handleUpdate failed: %v", err)
+	}
+
+	if !cmd.called || cmd.args != "hello" {
+		t.Fatalf("expected the registered command to be invoked with args %q, got called=%v args=%q", "hello", cmd.called, cmd.args)
+	}
+}
@@ -0,0 +1,133 @@
+package provider
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rate describes a token-bucket limit: at most Limit requests per Period,
+// refilling continuously. Mirrors the shape of ulule/limiter's Rate so a
+// RateLimiterStore can be backed by that library or a compatible one.
+type Rate struct {
+	Period time.Duration
+	Limit  int64
+}
+
+// RateLimiterStore tracks hits per key (e.g. a client IP or a fixed "global" key)
+// against a Rate, so in-memory and Redis-backed implementations can be swapped in.
+type RateLimiterStore interface {
+	// Take records a hit for key and reports whether it's allowed under rate. If not,
+	// retryAfter is how long the caller should wait before trying again.
+	Take(ctx context.Context, key string, rate Rate) (allowed bool, retryAfter time.Duration, err error)
+}
+
+// defaultPerIPRate, defaultGlobalRate and defaultMaxAuthRequests are applied by
+// initDefaults when the corresponding TelegramHandler field is left at its zero value.
+var (
+	defaultPerIPRate       = Rate{Period: time.Minute, Limit: 5}
+	defaultGlobalRate      = Rate{Period: time.Minute, Limit: 500}
+	defaultMaxAuthRequests = 10000
+)
+
+type rateBucket struct {
+	tokens     float64
+	lastRefill time.Time
+}
+
+// memoryRateLimiterStore is the default RateLimiterStore, backed by an in-process
+// map of token buckets. Like memoryAuthRequestStore, it isn't shared between replicas.
+type memoryRateLimiterStore struct {
+	mu      sync.Mutex
+	buckets map[string]*rateBucket
+}
+
+// NewMemoryRateLimiterStore returns the default in-memory RateLimiterStore implementation
+func NewMemoryRateLimiterStore() RateLimiterStore {
+	return &memoryRateLimiterStore{buckets: make(map[string]*rateBucket)}
+}
+
+func (s *memoryRateLimiterStore) Take(ctx context.Context, key string, rate Rate) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &rateBucket{tokens: float64(rate.Limit), lastRefill: now}
+		s.buckets[key] = b
+	}
+
+	refillRate := float64(rate.Limit) / rate.Period.Seconds()
+	b.tokens += now.Sub(b.lastRefill).Seconds() * refillRate
+	if b.tokens > float64(rate.Limit) {
+		b.tokens = float64(rate.Limit)
+	}
+	b.lastRefill = now
+
+	if b.tokens < 1 {
+		retryAfter := time.Duration((1 - b.tokens) / refillRate * float64(time.Second))
+		return false, retryAfter, nil
+	}
+
+	b.tokens--
+	return true, 0, nil
+}
+
+// rateLimited checks r against RateLimit (per client IP) and GlobalRateLimit, reporting
+// the Retry-After duration of the first of the two that is exceeded.
+//
+// The per-IP bucket is checked first and the global bucket is only debited once the
+// per-IP check passes: otherwise a client already capped by its own bucket could keep
+// hammering LoginHandler and drain the shared global bucket on every rejected attempt,
+// locking out other clients without ever being allowed through itself.
+func (t *TelegramHandler) rateLimited(r *http.Request) (limited bool, retryAfter time.Duration) {
+	if t.RateLimiter == nil {
+		return false, 0
+	}
+
+	checks := []struct {
+		key  string
+		rate Rate
+	}{
+		{"ip:" + clientIP(r, t.TrustForwardedFor), t.RateLimit},
+		{"global", t.GlobalRateLimit},
+	}
+
+	for _, c := range checks {
+		if c.rate.Limit <= 0 {
+			continue
+		}
+
+		allowed, wait, err := t.RateLimiter.Take(r.Context(), c.key, c.rate)
+		if err != nil {
+			t.Logf("rate limiter check failed, allowing request: %v", err)
+			continue
+		}
+
+		if !allowed {
+			return true, wait
+		}
+	}
+
+	return false, 0
+}
+
+// clientIP returns the address used to key per-IP rate limiting. X-Forwarded-For is
+// only honored when trustForwardedFor is set, since it's trivially spoofable otherwise.
+func clientIP(r *http.Request, trustForwardedFor bool) string {
+	if trustForwardedFor {
+		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+			return strings.TrimSpace(strings.Split(xff, ",")[0])
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,103 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMemoryRateLimiterStore_Take(t *testing.T) {
+	store := NewMemoryRateLimiterStore()
+	rate := Rate{Period: time.Minute, Limit: 2}
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		allowed, _, err := store.Take(ctx, "key", rate)
+		if err != nil {
+			t.Fatalf("request %d: unexpected error: %v", i, err)
+		}
+		if !allowed {
+			t.Fatalf("request %d should be allowed, limit not yet reached", i)
+		}
+	}
+
+	allowed, retryAfter, err := store.Take(ctx, "key", rate)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if allowed {
+		t.Fatal("third request should be rate limited")
+	}
+	if retryAfter <= 0 {
+		t.Fatalf("expected a positive retry-after, got %v", retryAfter)
+	}
+}
+
+func TestMemoryRateLimiterStore_Refill(t *testing.T) {
+	store := NewMemoryRateLimiterStore()
+	rate := Rate{Period: 50 * time.Millisecond, Limit: 1}
+	ctx := context.Background()
+
+	allowed, _, err := store.Take(ctx, "key", rate)
+	if err != nil || !allowed {
+		t.Fatalf("first request should be allowed, got allowed=%v err=%v", allowed, err)
+	}
+
+	allowed, _, err = store.Take(ctx, "key", rate)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if allowed {
+		t.Fatal("second immediate request should be rate limited")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	allowed, _, err = store.Take(ctx, "key", rate)
+	if err != nil || !allowed {
+		t.Fatalf("request after the bucket refills should be allowed, got allowed=%v err=%v", allowed, err)
+	}
+}
+
+func TestMemoryRateLimiterStore_IndependentKeys(t *testing.T) {
+	store := NewMemoryRateLimiterStore()
+	rate := Rate{Period: time.Minute, Limit: 1}
+	ctx := context.Background()
+
+	if allowed, _, err := store.Take(ctx, "a", rate); err != nil || !allowed {
+		t.Fatalf("key a should be allowed, got allowed=%v err=%v", allowed, err)
+	}
+	if allowed, _, err := store.Take(ctx, "b", rate); err != nil || !allowed {
+		t.Fatalf("key b should be allowed independently of key a, got allowed=%v err=%v", allowed, err)
+	}
+	if allowed, _, err := store.Take(ctx, "a", rate); err != nil || allowed {
+		t.Fatalf("key a should already be exhausted, got allowed=%v err=%v", allowed, err)
+	}
+}
+
+// TestTelegramHandler_rateLimited_PerIPExhaustionDoesNotDrainGlobal makes sure a client
+// already capped by its own per-IP bucket can't keep consuming the shared global bucket,
+// which would let it lock out unrelated clients despite never getting through itself.
+func TestTelegramHandler_rateLimited_PerIPExhaustionDoesNotDrainGlobal(t *testing.T) {
+	h := &TelegramHandler{
+		RateLimiter:     NewMemoryRateLimiterStore(),
+		RateLimit:       Rate{Period: time.Minute, Limit: 1},
+		GlobalRateLimit: Rate{Period: time.Minute, Limit: 3},
+	}
+
+	attacker := httptest.NewRequest(http.MethodGet, "/", nil)
+	attacker.RemoteAddr = "10.0.0.1:1234"
+
+	for i := 0; i < 10; i++ {
+		h.rateLimited(attacker)
+	}
+
+	victim := httptest.NewRequest(http.MethodGet, "/", nil)
+	victim.RemoteAddr = "10.0.0.2:1234"
+
+	if limited, _ := h.rateLimited(victim); limited {
+		t.Fatal("a different IP's first request should not be limited by the attacker exhausting its own per-IP bucket")
+	}
+}
@@ -0,0 +1,153 @@
+package provider
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// SQLAuthRequestStoreSchema creates the table expected by SQLAuthRequestStore.
+const SQLAuthRequestStoreSchema = `
+CREATE TABLE IF NOT EXISTS auth_requests (
+	token       TEXT PRIMARY KEY,
+	expires     TIMESTAMP NOT NULL,
+	confirmed   BOOLEAN NOT NULL DEFAULT FALSE,
+	cancelled   BOOLEAN NOT NULL DEFAULT FALSE,
+	user_id     INTEGER,
+	user_name   TEXT,
+	user_avatar TEXT
+)`
+
+// SQLAuthRequestStore is an AuthRequestStore backed by database/sql, so pending and
+// confirmed logins survive restarts and are visible to every replica of the service.
+// Queries use ? placeholders, which work as-is with the mysql and sqlite3 drivers;
+// rebind them to $N first if used against postgres.
+type SQLAuthRequestStore struct {
+	DB *sql.DB
+}
+
+// NewSQLAuthRequestStore returns an AuthRequestStore backed by db. The auth_requests
+// table (see SQLAuthRequestStoreSchema) must already exist.
+func NewSQLAuthRequestStore(db *sql.DB) *SQLAuthRequestStore {
+	return &SQLAuthRequestStore{DB: db}
+}
+
+// Create registers a freshly issued token, pending confirmation, expiring at expires.
+func (s *SQLAuthRequestStore) Create(ctx context.Context, token string, expires time.Time) error {
+	_, err := s.DB.ExecContext(ctx, `INSERT INTO auth_requests (token, expires) VALUES (?, ?)`, token, expires)
+	if err != nil {
+		return errors.Wrap(err, "failed to insert auth request")
+	}
+	return nil
+}
+
+// CreateIfUnderLimit atomically inserts token only if fewer than maxOutstanding rows
+// currently exist in auth_requests. The count check and insert are a single statement,
+// so concurrent callers can't both read a count under the limit and both insert.
+func (s *SQLAuthRequestStore) CreateIfUnderLimit(ctx context.Context, token string, expires time.Time, maxOutstanding int) (bool, error) {
+	res, err := s.DB.ExecContext(ctx,
+		`INSERT INTO auth_requests (token, expires) SELECT ?, ? WHERE (SELECT COUNT(*) FROM auth_requests) < ?`,
+		token, expires, maxOutstanding)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to insert auth request")
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to insert auth request")
+	}
+
+	return n > 0, nil
+}
+
+// Get returns the request for token, or ErrNotFound if it doesn't exist.
+func (s *SQLAuthRequestStore) Get(ctx context.Context, token string) (authRequestInfo, error) {
+	var (
+		req                  authRequestInfo
+		userID               sql.NullInt64
+		userName, userAvatar sql.NullString
+	)
+
+	row := s.DB.QueryRowContext(ctx,
+		`SELECT expires, confirmed, cancelled, user_id, user_name, user_avatar FROM auth_requests WHERE token = ?`, token)
+
+	if err := row.Scan(&req.expires, &req.confirmed, &req.cancelled, &userID, &userName, &userAvatar); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return authRequestInfo{}, ErrNotFound
+		}
+		return authRequestInfo{}, errors.Wrap(err, "failed to query auth request")
+	}
+
+	if req.confirmed {
+		req.user = &userInfo{ID: int(userID.Int64), Name: userName.String, Avatar: userAvatar.String}
+	}
+
+	return req, nil
+}
+
+// Confirm marks token as confirmed by user. Returns ErrNotFound if the token doesn't exist.
+func (s *SQLAuthRequestStore) Confirm(ctx context.Context, token string, user userInfo) error {
+	res, err := s.DB.ExecContext(ctx,
+		`UPDATE auth_requests SET confirmed = TRUE, user_id = ?, user_name = ?, user_avatar = ? WHERE token = ?`,
+		user.ID, user.Name, user.Avatar, token)
+	if err != nil {
+		return errors.Wrap(err, "failed to confirm auth request")
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "failed to confirm auth request")
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Cancel marks token as cancelled by the peer. Returns ErrNotFound if the token doesn't exist.
+func (s *SQLAuthRequestStore) Cancel(ctx context.Context, token string) error {
+	res, err := s.DB.ExecContext(ctx, `UPDATE auth_requests SET cancelled = TRUE WHERE token = ?`, token)
+	if err != nil {
+		return errors.Wrap(err, "failed to cancel auth request")
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "failed to cancel auth request")
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Delete removes token, e.g. once it has been exchanged for a session.
+func (s *SQLAuthRequestStore) Delete(ctx context.Context, token string) error {
+	_, err := s.DB.ExecContext(ctx, `DELETE FROM auth_requests WHERE token = ?`, token)
+	if err != nil {
+		return errors.Wrap(err, "failed to delete auth request")
+	}
+	return nil
+}
+
+// PurgeExpired removes all requests that expired before now.
+func (s *SQLAuthRequestStore) PurgeExpired(ctx context.Context, now time.Time) error {
+	_, err := s.DB.ExecContext(ctx, `DELETE FROM auth_requests WHERE expires < ?`, now)
+	if err != nil {
+		return errors.Wrap(err, "failed to purge expired auth requests")
+	}
+	return nil
+}
+
+// Count returns the number of rows in auth_requests, expired or not.
+func (s *SQLAuthRequestStore) Count(ctx context.Context) (int, error) {
+	var n int
+	if err := s.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM auth_requests`).Scan(&n); err != nil {
+		return 0, errors.Wrap(err, "failed to count auth requests")
+	}
+	return n, nil
+}
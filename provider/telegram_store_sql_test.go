@@ -0,0 +1,301 @@
+package provider
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// The rest of this file is a tiny hand-rolled database/sql driver, just capable enough
+// to run the exact statements SQLAuthRequestStore issues against an in-memory table. It
+// lets the store be exercised as a real database/sql.DB round trip without pulling in an
+// actual database driver, which isn't available in this environment.
+
+type fakeSQLRow struct {
+	expires              time.Time
+	confirmed, cancelled bool
+	userID               sql.NullInt64
+	userName, userAvatar sql.NullString
+}
+
+type fakeSQLDB struct {
+	mu   sync.Mutex
+	rows map[string]*fakeSQLRow
+}
+
+var fakeSQLDBs = struct {
+	mu sync.Mutex
+	m  map[string]*fakeSQLDB
+}{m: map[string]*fakeSQLDB{}}
+
+func newFakeSQLDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	name := t.Name()
+	fakeSQLDBs.mu.Lock()
+	fakeSQLDBs.m[name] = &fakeSQLDB{rows: map[string]*fakeSQLRow{}}
+	fakeSQLDBs.mu.Unlock()
+
+	db, err := sql.Open("faketelegram", name)
+	if err != nil {
+		t.Fatalf("failed to open fake db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+type fakeSQLDriver struct{}
+
+func (fakeSQLDriver) Open(name string) (driver.Conn, error) {
+	fakeSQLDBs.mu.Lock()
+	db, ok := fakeSQLDBs.m[name]
+	fakeSQLDBs.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no fake db registered for %q", name)
+	}
+	return &fakeSQLConn{db: db}, nil
+}
+
+func init() {
+	sql.Register("faketelegram", fakeSQLDriver{})
+}
+
+type fakeSQLConn struct{ db *fakeSQLDB }
+
+func (c *fakeSQLConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeSQLStmt{db: c.db, query: query}, nil
+}
+func (c *fakeSQLConn) Close() error              { return nil }
+func (c *fakeSQLConn) Begin() (driver.Tx, error) { return nil, errors.New("transactions not supported") }
+
+type fakeSQLStmt struct {
+	db    *fakeSQLDB
+	query string
+}
+
+func (s *fakeSQLStmt) Close() error { return nil }
+
+func (s *fakeSQLStmt) NumInput() int { return -1 } // let database/sql skip arg-count validation
+
+func (s *fakeSQLStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	switch s.query {
+	case `INSERT INTO auth_requests (token, expires) VALUES (?, ?)`:
+		token := args[0].(string)
+		s.db.rows[token] = &fakeSQLRow{expires: args[1].(time.Time)}
+		return fakeSQLResult{rowsAffected: 1}, nil
+
+	case `INSERT INTO auth_requests (token, expires) SELECT ?, ? WHERE (SELECT COUNT(*) FROM auth_requests) < ?`:
+		token, expires, max := args[0].(string), args[1].(time.Time), args[2].(int64)
+		if int64(len(s.db.rows)) >= max {
+			return fakeSQLResult{rowsAffected: 0}, nil
+		}
+		s.db.rows[token] = &fakeSQLRow{expires: expires}
+		return fakeSQLResult{rowsAffected: 1}, nil
+
+	case `UPDATE auth_requests SET confirmed = TRUE, user_id = ?, user_name = ?, user_avatar = ? WHERE token = ?`:
+		token := args[3].(string)
+		row, ok := s.db.rows[token]
+		if !ok {
+			return fakeSQLResult{rowsAffected: 0}, nil
+		}
+		row.confirmed = true
+		row.userID = sql.NullInt64{Int64: args[0].(int64), Valid: true}
+		row.userName = sql.NullString{String: args[1].(string), Valid: true}
+		row.userAvatar = sql.NullString{String: args[2].(string), Valid: true}
+		return fakeSQLResult{rowsAffected: 1}, nil
+
+	case `UPDATE auth_requests SET cancelled = TRUE WHERE token = ?`:
+		token := args[0].(string)
+		row, ok := s.db.rows[token]
+		if !ok {
+			return fakeSQLResult{rowsAffected: 0}, nil
+		}
+		row.cancelled = true
+		return fakeSQLResult{rowsAffected: 1}, nil
+
+	case `DELETE FROM auth_requests WHERE token = ?`:
+		token := args[0].(string)
+		if _, ok := s.db.rows[token]; !ok {
+			return fakeSQLResult{rowsAffected: 0}, nil
+		}
+		delete(s.db.rows, token)
+		return fakeSQLResult{rowsAffected: 1}, nil
+
+	case `DELETE FROM auth_requests WHERE expires < ?`:
+		cutoff := args[0].(time.Time)
+		var n int64
+		for token, row := range s.db.rows {
+			if row.expires.Before(cutoff) {
+				delete(s.db.rows, token)
+				n++
+			}
+		}
+		return fakeSQLResult{rowsAffected: n}, nil
+
+	default:
+		return nil, fmt.Errorf("fake driver: unsupported exec query: %s", s.query)
+	}
+}
+
+func (s *fakeSQLStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	switch s.query {
+	case `SELECT expires, confirmed, cancelled, user_id, user_name, user_avatar FROM auth_requests WHERE token = ?`:
+		token := args[0].(string)
+		row, ok := s.db.rows[token]
+		if !ok {
+			return &fakeSQLRows{}, nil
+		}
+
+		var userID driver.Value
+		if row.userID.Valid {
+			userID = row.userID.Int64
+		}
+		var userName, userAvatar driver.Value
+		if row.userName.Valid {
+			userName = row.userName.String
+		}
+		if row.userAvatar.Valid {
+			userAvatar = row.userAvatar.String
+		}
+
+		return &fakeSQLRows{values: [][]driver.Value{{row.expires, row.confirmed, row.cancelled, userID, userName, userAvatar}}}, nil
+
+	case `SELECT COUNT(*) FROM auth_requests`:
+		return &fakeSQLRows{values: [][]driver.Value{{int64(len(s.db.rows))}}}, nil
+
+	default:
+		return nil, fmt.Errorf("fake driver: unsupported query: %s", s.query)
+	}
+}
+
+type fakeSQLResult struct{ rowsAffected int64 }
+
+func (r fakeSQLResult) LastInsertId() (int64, error) { return 0, errors.New("not supported") }
+func (r fakeSQLResult) RowsAffected() (int64, error) { return r.rowsAffected, nil }
+
+type fakeSQLRows struct {
+	values [][]driver.Value
+	pos    int
+}
+
+func (r *fakeSQLRows) Columns() []string {
+	if len(r.values) == 0 {
+		return nil
+	}
+	return make([]string, len(r.values[0])) // database/sql only needs the column count
+}
+func (r *fakeSQLRows) Close() error { return nil }
+func (r *fakeSQLRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.values) {
+		return sql.ErrNoRows
+	}
+	copy(dest, r.values[r.pos])
+	r.pos++
+	return nil
+}
+
+func TestSQLAuthRequestStore_RoundTrip(t *testing.T) {
+	store := NewSQLAuthRequestStore(newFakeSQLDB(t))
+	ctx := context.Background()
+	now := time.Now()
+
+	if _, err := store.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for an unknown token, got %v", err)
+	}
+
+	if err := store.Create(ctx, "tok1", now.Add(time.Hour)); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	req, err := store.Get(ctx, "tok1")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if req.confirmed || req.cancelled || req.user != nil {
+		t.Fatalf("freshly created request should be unconfirmed with no user, got %+v", req)
+	}
+
+	if err := store.Confirm(ctx, "tok1", userInfo{ID: 42, Name: "Alice", Avatar: "http://a"}); err != nil {
+		t.Fatalf("Confirm failed: %v", err)
+	}
+
+	req, err = store.Get(ctx, "tok1")
+	if err != nil {
+		t.Fatalf("Get after Confirm failed: %v", err)
+	}
+	if !req.confirmed || req.user == nil || req.user.ID != 42 || req.user.Name != "Alice" {
+		t.Fatalf("expected a confirmed request with user set, got %+v", req)
+	}
+
+	if err := store.Create(ctx, "tok2", now.Add(time.Hour)); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := store.Cancel(ctx, "tok2"); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+	req, err = store.Get(ctx, "tok2")
+	if err != nil || !req.cancelled {
+		t.Fatalf("expected tok2 to be cancelled, got req=%+v err=%v", req, err)
+	}
+
+	if err := store.Cancel(ctx, "nope"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound cancelling an unknown token, got %v", err)
+	}
+
+	if n, err := store.Count(ctx); err != nil || n != 2 {
+		t.Fatalf("expected Count to report 2 outstanding requests, got n=%d err=%v", n, err)
+	}
+
+	if err := store.Create(ctx, "expired", now.Add(-time.Hour)); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := store.PurgeExpired(ctx, now); err != nil {
+		t.Fatalf("PurgeExpired failed: %v", err)
+	}
+	if _, err := store.Get(ctx, "expired"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected the expired request to be purged, got %v", err)
+	}
+
+	if err := store.Delete(ctx, "tok1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := store.Get(ctx, "tok1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected tok1 to be gone after Delete, got %v", err)
+	}
+}
+
+func TestSQLAuthRequestStore_CreateIfUnderLimit(t *testing.T) {
+	store := NewSQLAuthRequestStore(newFakeSQLDB(t))
+	ctx := context.Background()
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		created, err := store.CreateIfUnderLimit(ctx, fmt.Sprintf("tok%d", i), now.Add(time.Hour), 2)
+		if err != nil || !created {
+			t.Fatalf("request %d should have been created, got created=%v err=%v", i, created, err)
+		}
+	}
+
+	created, err := store.CreateIfUnderLimit(ctx, "tok-over", now.Add(time.Hour), 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if created {
+		t.Fatal("expected CreateIfUnderLimit to refuse once the limit is reached")
+	}
+
+	if n, err := store.Count(ctx); err != nil || n != 2 {
+		t.Fatalf("expected exactly 2 stored requests, got n=%d err=%v", n, err)
+	}
+}
@@ -0,0 +1,135 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryAuthRequestStore_RoundTrip(t *testing.T) {
+	store := NewMemoryAuthRequestStore()
+	ctx := context.Background()
+	now := time.Now()
+
+	if _, err := store.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for an unknown token, got %v", err)
+	}
+
+	if err := store.Create(ctx, "tok1", now.Add(time.Hour)); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	req, err := store.Get(ctx, "tok1")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if req.confirmed || req.cancelled || req.user != nil {
+		t.Fatalf("freshly created request should be unconfirmed with no user, got %+v", req)
+	}
+
+	if err := store.Confirm(ctx, "tok1", userInfo{ID: 42, Name: "Alice", Avatar: "http://a"}); err != nil {
+		t.Fatalf("Confirm failed: %v", err)
+	}
+
+	req, err = store.Get(ctx, "tok1")
+	if err != nil {
+		t.Fatalf("Get after Confirm failed: %v", err)
+	}
+	if !req.confirmed || req.user == nil || req.user.ID != 42 || req.user.Name != "Alice" {
+		t.Fatalf("expected a confirmed request with user set, got %+v", req)
+	}
+
+	if err := store.Confirm(ctx, "nope", userInfo{}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound confirming an unknown token, got %v", err)
+	}
+
+	if err := store.Create(ctx, "tok2", now.Add(time.Hour)); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := store.Cancel(ctx, "tok2"); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+	req, err = store.Get(ctx, "tok2")
+	if err != nil || !req.cancelled {
+		t.Fatalf("expected tok2 to be cancelled, got req=%+v err=%v", req, err)
+	}
+
+	if n, err := store.Count(ctx); err != nil || n != 2 {
+		t.Fatalf("expected Count to report 2 outstanding requests, got n=%d err=%v", n, err)
+	}
+
+	if err := store.Create(ctx, "expired", now.Add(-time.Hour)); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := store.PurgeExpired(ctx, now); err != nil {
+		t.Fatalf("PurgeExpired failed: %v", err)
+	}
+	if _, err := store.Get(ctx, "expired"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected the expired request to be purged, got %v", err)
+	}
+
+	if err := store.Delete(ctx, "tok1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := store.Get(ctx, "tok1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected tok1 to be gone after Delete, got %v", err)
+	}
+}
+
+func TestMemoryAuthRequestStore_CreateIfUnderLimit(t *testing.T) {
+	store := NewMemoryAuthRequestStore()
+	ctx := context.Background()
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		created, err := store.CreateIfUnderLimit(ctx, string(rune('a'+i)), now.Add(time.Hour), 2)
+		if err != nil || !created {
+			t.Fatalf("request %d should have been created, got created=%v err=%v", i, created, err)
+		}
+	}
+
+	created, err := store.CreateIfUnderLimit(ctx, "over", now.Add(time.Hour), 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if created {
+		t.Fatal("expected CreateIfUnderLimit to refuse once the limit is reached")
+	}
+
+	if n, err := store.Count(ctx); err != nil || n != 2 {
+		t.Fatalf("expected exactly 2 stored requests, got n=%d err=%v", n, err)
+	}
+}
+
+// TestMemoryAuthRequestStore_CreateIfUnderLimit_Concurrent guards against the
+// count-then-act race: many goroutines racing CreateIfUnderLimit against the same
+// store must never let more than maxOutstanding requests land.
+func TestMemoryAuthRequestStore_CreateIfUnderLimit_Concurrent(t *testing.T) {
+	store := NewMemoryAuthRequestStore()
+	ctx := context.Background()
+	now := time.Now()
+	const maxOutstanding = 5
+
+	results := make(chan bool, 50)
+	for i := 0; i < 50; i++ {
+		go func(i int) {
+			created, err := store.CreateIfUnderLimit(ctx, string(rune(i)), now.Add(time.Hour), maxOutstanding)
+			if err != nil {
+				t.Error(err)
+			}
+			results <- created
+		}(i)
+	}
+
+	created := 0
+	for i := 0; i < 50; i++ {
+		if <-results {
+			created++
+		}
+	}
+
+	if created != maxOutstanding {
+		t.Fatalf("expected exactly %d requests to win the race, got %d", maxOutstanding, created)
+	}
+}
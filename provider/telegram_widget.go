@@ -0,0 +1,144 @@
+package provider
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/go-pkgz/auth/logger"
+	authtoken "github.com/go-pkgz/auth/token"
+	"github.com/go-pkgz/rest"
+	"github.com/pkg/errors"
+)
+
+// defaultWidgetFreshnessTTL is used by TelegramWidgetHandler when FreshnessTTL isn't set.
+const defaultWidgetFreshnessTTL = 24 * time.Hour
+
+// TelegramWidgetHandler implements login via the Telegram Login Widget
+// (https://core.telegram.org/widgets/login), a browser-side alternative to
+// TelegramHandler's bot deep-link + poll flow: the widget redirects back to us
+// with user params signed by Telegram, which LoginHandler verifies.
+type TelegramWidgetHandler struct {
+	logger.L
+
+	ProviderName string
+	BotToken     string
+	FreshnessTTL time.Duration // max age of auth_date. Defaults to defaultWidgetFreshnessTTL
+
+	TokenService TokenService
+	AvatarSaver  AvatarSaver
+}
+
+// Name of the handler
+func (t *TelegramWidgetHandler) Name() string { return t.ProviderName }
+
+// LoginHandler validates the widget callback and sets the auth token.
+// GET /login?id=...&first_name=...&username=...&photo_url=...&auth_date=...&hash=...
+func (t *TelegramWidgetHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	if err := t.validate(params); err != nil {
+		rest.SendErrorJSON(w, r, t.L, http.StatusForbidden, err, "failed to validate telegram widget callback")
+		return
+	}
+
+	id := params.Get("id")
+	name := params.Get("first_name")
+	if username := params.Get("username"); username != "" {
+		name = username
+	}
+
+	u := authtoken.User{
+		Name:    name,
+		ID:      t.ProviderName + "_" + authtoken.HashID(sha1.New(), id),
+		Picture: params.Get("photo_url"),
+	}
+
+	u, err := setAvatar(t.AvatarSaver, u, &http.Client{Timeout: 5 * time.Second})
+	if err != nil {
+		rest.SendErrorJSON(w, r, t.L, http.StatusInternalServerError, err, "failed to save avatar to proxy")
+		return
+	}
+
+	claims := authtoken.Claims{
+		User: &u,
+		StandardClaims: jwt.StandardClaims{
+			Id:     id,
+			Issuer: t.ProviderName,
+		},
+		SessionOnly: false,
+	}
+
+	if _, err := t.TokenService.Set(w, claims); err != nil {
+		rest.SendErrorJSON(w, r, t.L, http.StatusInternalServerError, err, "failed to set token")
+		return
+	}
+
+	rest.RenderJSON(w, r, claims.User)
+}
+
+// validate checks hash against the HMAC-SHA256 data-check-string per
+// core.telegram.org/widgets/login#checking-authorization, and rejects callbacks
+// whose auth_date is older than FreshnessTTL.
+func (t *TelegramWidgetHandler) validate(params neturl.Values) error {
+	hash := params.Get("hash")
+	if hash == "" {
+		return errors.New("missing hash")
+	}
+
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		if k == "hash" {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + params.Get(k)
+	}
+
+	secretKey := sha256.Sum256([]byte(t.BotToken))
+	mac := hmac.New(sha256.New, secretKey[:])
+	mac.Write([]byte(strings.Join(pairs, "\n")))
+	expected := fmt.Sprintf("%x", mac.Sum(nil))
+
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(hash)) != 1 {
+		return errors.New("hash mismatch")
+	}
+
+	authDate, err := strconv.ParseInt(params.Get("auth_date"), 10, 64)
+	if err != nil {
+		return errors.Wrap(err, "invalid auth_date")
+	}
+
+	freshness := t.FreshnessTTL
+	if freshness == 0 {
+		freshness = defaultWidgetFreshnessTTL
+	}
+
+	if time.Since(time.Unix(authDate, 0)) > freshness {
+		return errors.New("auth_date too old")
+	}
+
+	return nil
+}
+
+// AuthHandler does nothing, the widget redirect already carries everything LoginHandler needs
+func (t *TelegramWidgetHandler) AuthHandler(w http.ResponseWriter, r *http.Request) {}
+
+// LogoutHandler - GET /logout
+func (t *TelegramWidgetHandler) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	t.TokenService.Reset(w)
+}
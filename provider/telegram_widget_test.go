@@ -0,0 +1,103 @@
+package provider
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signWidgetParams computes the hash Telegram would attach to params, mirroring
+// TelegramWidgetHandler.validate so tests can build well-formed callbacks.
+func signWidgetParams(botToken string, params url.Values) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		if k == "hash" {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + params.Get(k)
+	}
+
+	secretKey := sha256.Sum256([]byte(botToken))
+	mac := hmac.New(sha256.New, secretKey[:])
+	mac.Write([]byte(strings.Join(pairs, "\n")))
+
+	return fmt.Sprintf("%x", mac.Sum(nil))
+}
+
+func TestTelegramWidgetHandler_validate(t *testing.T) {
+	const botToken = "test-bot-token"
+	h := &TelegramWidgetHandler{BotToken: botToken}
+
+	fresh := func() url.Values {
+		return url.Values{
+			"id":         {"123456"},
+			"first_name": {"Alice"},
+			"auth_date":  {strconv.FormatInt(time.Now().Unix(), 10)},
+		}
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		params := fresh()
+		params.Set("hash", signWidgetParams(botToken, params))
+
+		if err := h.validate(params); err != nil {
+			t.Fatalf("expected valid params to pass, got: %v", err)
+		}
+	})
+
+	t.Run("tampered param", func(t *testing.T) {
+		params := fresh()
+		params.Set("hash", signWidgetParams(botToken, params))
+		params.Set("first_name", "Mallory") // changed after signing
+
+		if err := h.validate(params); err == nil {
+			t.Fatal("expected tampered params to fail validation")
+		}
+	})
+
+	t.Run("missing hash", func(t *testing.T) {
+		if err := h.validate(fresh()); err == nil {
+			t.Fatal("expected missing hash to fail validation")
+		}
+	})
+
+	t.Run("stale auth_date", func(t *testing.T) {
+		params := url.Values{
+			"id":         {"123456"},
+			"first_name": {"Alice"},
+			"auth_date":  {strconv.FormatInt(time.Now().Add(-48*time.Hour).Unix(), 10)},
+		}
+		params.Set("hash", signWidgetParams(botToken, params))
+
+		if err := h.validate(params); err == nil {
+			t.Fatal("expected a stale auth_date to fail validation")
+		}
+	})
+
+	t.Run("auth_date within a custom FreshnessTTL", func(t *testing.T) {
+		h := &TelegramWidgetHandler{BotToken: botToken, FreshnessTTL: 72 * time.Hour}
+
+		params := url.Values{
+			"id":         {"123456"},
+			"first_name": {"Alice"},
+			"auth_date":  {strconv.FormatInt(time.Now().Add(-48*time.Hour).Unix(), 10)},
+		}
+		params.Set("hash", signWidgetParams(botToken, params))
+
+		if err := h.validate(params); err != nil {
+			t.Fatalf("expected auth_date within custom FreshnessTTL to pass, got: %v", err)
+		}
+	})
+}